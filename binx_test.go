@@ -1,17 +1,81 @@
 package main
 
 import (
+	"bytes"
+	"reflect"
 	"testing"
 )
 
-func Test_findBytePattern(t *testing.T) {
+func Test_ParseQuery_hex(t *testing.T) {
 	buf := []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}
 
-	pos, err := findBytePattern("09", buf)
+	q, err := ParseQuery("09")
 	if err != nil {
-		t.Errorf("Couldn't find byte pattern: %s", err.Error())
+		t.Fatalf("Couldn't parse hex query: %s", err.Error())
 	}
-	if pos != int64(buf[pos]) {
-		t.Errorf("Couldn't find byte at pos %d", pos)
+	hits := q.FindAll(buf)
+	if !reflect.DeepEqual(hits, []int64{9}) {
+		t.Errorf("expected hit at offset 9, got %v", hits)
+	}
+}
+
+func Test_ParseQuery_hexWildcard(t *testing.T) {
+	buf := []byte{0x48, 0x8b, 0x12, 0x24, 0x48, 0x8b, 0xff, 0x24}
+
+	q, err := ParseQuery("48 8b ?? 24")
+	if err != nil {
+		t.Fatalf("Couldn't parse wildcard query: %s", err.Error())
+	}
+	hits := q.FindAll(buf)
+	if !reflect.DeepEqual(hits, []int64{0, 4}) {
+		t.Errorf("expected hits at 0 and 4, got %v", hits)
+	}
+}
+
+func Test_ParseQuery_stringLiteral(t *testing.T) {
+	buf := []byte("xxhelloxx")
+
+	q, err := ParseQuery(`"hello"`)
+	if err != nil {
+		t.Fatalf("Couldn't parse string query: %s", err.Error())
+	}
+	hits := q.FindAll(buf)
+	if !reflect.DeepEqual(hits, []int64{2}) {
+		t.Errorf("expected hit at offset 2, got %v", hits)
+	}
+}
+
+func Test_ParseQuery_numericLiteral(t *testing.T) {
+	buf := []byte{0x00, 0xef, 0xbe, 0xad, 0xde, 0x00}
+
+	q, err := ParseQuery("u32le:0xdeadbeef")
+	if err != nil {
+		t.Fatalf("Couldn't parse numeric query: %s", err.Error())
+	}
+	hits := q.FindAll(buf)
+	if !reflect.DeepEqual(hits, []int64{1}) {
+		t.Errorf("expected hit at offset 1, got %v", hits)
+	}
+}
+
+func Test_FindAllInSource_matchesFindAllAcrossWindowBoundaries(t *testing.T) {
+	buf := make([]byte, searchWindowSize*2+17)
+	for i := range buf {
+		buf[i] = byte(i)
+	}
+	// Plant hits that straddle where a naive, non-overlapping window
+	// split would cut the buffer.
+	copy(buf[searchWindowSize-2:], []byte{0xde, 0xad, 0xbe, 0xef})
+	copy(buf[searchWindowSize*2-2:], []byte{0xde, 0xad, 0xbe, 0xef})
+
+	q, err := ParseQuery("de ad be ef")
+	if err != nil {
+		t.Fatalf("Couldn't parse hex query: %s", err.Error())
+	}
+
+	want := q.FindAll(buf)
+	got := q.FindAllInSource(bytes.NewReader(buf), int64(len(buf)))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAllInSource = %v, want %v", got, want)
 	}
 }
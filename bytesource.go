@@ -0,0 +1,210 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/exp/mmap"
+)
+
+// pageSize is the granularity pagedSource reads and caches data in.
+const pageSize = 64 * 1024
+
+// lruCacheSize is the number of pages pagedSource keeps resident before
+// evicting the least recently used one.
+const lruCacheSize = 256
+
+// errPageEvicted is returned by pagedSource.ReadAt for bytes whose page
+// was already evicted and can't be read again from a forward-only stream.
+var errPageEvicted = errors.New("binx: page evicted from a forward-only source")
+
+// ByteSource abstracts over where binx's bytes come from, so the rest
+// of the program can address a file by (offset, length) instead of
+// holding it as one big in-memory slice. A regular file is mapped
+// straight into memory; anything that can only be read sequentially
+// (stdin, a network stream) is served out of a small LRU page cache
+// instead.
+type ByteSource interface {
+	// ReadAt reads len(p) bytes starting at off, following io.ReaderAt
+	// semantics.
+	ReadAt(p []byte, off int64) (int, error)
+	// Len reports the total size of the source, in bytes.
+	Len() int64
+	Close() error
+}
+
+// mmapSource is a ByteSource backed by a memory-mapped file.
+type mmapSource struct {
+	r *mmap.ReaderAt
+}
+
+func (m *mmapSource) ReadAt(p []byte, off int64) (int, error) { return m.r.ReadAt(p, off) }
+func (m *mmapSource) Len() int64                              { return int64(m.r.Len()) }
+func (m *mmapSource) Close() error                            { return m.r.Close() }
+
+// pagedSource is a ByteSource over an io.Reader that can't be mapped or
+// seeked (stdin, a pipe). It buffers what's been read so far and serves
+// ReadAt out of fixed-size pages, reading further into the stream on
+// demand and evicting the least recently used page once the cache is
+// full.
+type pagedSource struct {
+	mutex sync.Mutex
+	r     io.Reader
+	buf   []byte // bytes read but not yet copied into a page, starting at stream offset base
+	base  int64
+	eof   bool
+	size  int64 // -1 until eof, then the total stream length
+
+	pages   map[int64][]byte
+	lru     *list.List
+	lruElem map[int64]*list.Element
+}
+
+func newPagedSource(r io.Reader) *pagedSource {
+	return &pagedSource{
+		r:       r,
+		size:    -1,
+		pages:   make(map[int64][]byte),
+		lru:     list.New(),
+		lruElem: make(map[int64]*list.Element),
+	}
+}
+
+// fill reads from the underlying reader until base+len(buf) reaches
+// the absolute stream offset n, or the stream ends, caching each
+// complete page as soon as it's read. This bounds buf to under one
+// page's worth of not-yet-cached data regardless of how far n reaches
+// ahead — Len()'s full-stream scan stays within the LRU budget instead
+// of accumulating the whole stream in one unbounded slice.
+func (p *pagedSource) fill(n int64) {
+	for p.base+int64(len(p.buf)) < n && !p.eof {
+		chunk := make([]byte, pageSize)
+		read, err := p.r.Read(chunk)
+		if read > 0 {
+			p.buf = append(p.buf, chunk[:read]...)
+			p.cacheCompletePages()
+		}
+		if err != nil {
+			p.eof = true
+			p.size = p.base + int64(len(p.buf))
+		}
+	}
+}
+
+// cacheCompletePages copies every full pageSize chunk currently sitting
+// at the front of buf into the LRU cache, trimming buf down to the
+// partial tail, so buf never holds more than one page's worth of bytes
+// that haven't been cached (and potentially evicted) yet.
+func (p *pagedSource) cacheCompletePages() {
+	for int64(len(p.buf)) >= pageSize {
+		pg := make([]byte, pageSize)
+		copy(pg, p.buf[:pageSize])
+		p.storePage(p.base/pageSize, pg)
+		p.buf = p.buf[pageSize:]
+		p.base += pageSize
+	}
+}
+
+// storePage caches pg as page idx and evicts the least recently used
+// page if the cache has grown past lruCacheSize.
+func (p *pagedSource) storePage(idx int64, pg []byte) {
+	p.pages[idx] = pg
+	p.lruElem[idx] = p.lru.PushFront(idx)
+	if p.lru.Len() > lruCacheSize {
+		oldest := p.lru.Back()
+		p.lru.Remove(oldest)
+		evictedIdx := oldest.Value.(int64)
+		delete(p.pages, evictedIdx)
+		delete(p.lruElem, evictedIdx)
+	}
+}
+
+// page returns the cached bytes for page idx, reading and caching it
+// first if necessary. It returns nil for a page that has already been
+// read past and evicted. Each page is copied into its own backing array,
+// so evicting a page actually frees its memory instead of leaving it
+// pinned in an ever-growing buf.
+func (p *pagedSource) page(idx int64) []byte {
+	if cached, ok := p.pages[idx]; ok {
+		p.lru.MoveToFront(p.lruElem[idx])
+		return cached
+	}
+	start := idx * pageSize
+	if start < p.base {
+		// This page's bytes have already been consumed from the
+		// underlying reader and dropped from buf; a forward-only
+		// source (a pipe, stdin) can't be rewound to refetch them.
+		return nil
+	}
+	p.fill(start + pageSize)
+	if cached, ok := p.pages[idx]; ok {
+		// fill cached this page as a complete pageSize chunk.
+		p.lru.MoveToFront(p.lruElem[idx])
+		return cached
+	}
+	// A partial trailing page: the stream hit EOF before a full
+	// pageSize chunk accumulated, so it's still sitting in buf.
+	bufEnd := p.base + int64(len(p.buf))
+	end := start + pageSize
+	if end > bufEnd {
+		end = bufEnd
+	}
+	if start > end {
+		start = end
+	}
+	pg := make([]byte, end-start)
+	copy(pg, p.buf[start-p.base:end-p.base])
+	p.storePage(idx, pg)
+	return pg
+}
+
+func (p *pagedSource) ReadAt(dst []byte, off int64) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	n := 0
+	for n < len(dst) {
+		idx := (off + int64(n)) / pageSize
+		pg := p.page(idx)
+		if pg == nil {
+			return n, errPageEvicted
+		}
+		pageOff := int((off + int64(n)) % pageSize)
+		if pageOff >= len(pg) {
+			return n, io.EOF
+		}
+		n += copy(dst[n:], pg[pageOff:])
+	}
+	return n, nil
+}
+
+func (p *pagedSource) Len() int64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.size < 0 {
+		p.fill(1<<63 - 1)
+	}
+	return p.size
+}
+
+func (p *pagedSource) Close() error { return nil }
+
+// OpenByteSource opens filename as a ByteSource, preferring an mmap so a
+// file never has to be fully resident in memory. "-" reads stdin through
+// the paged cache instead, since a pipe can't be mapped or seeked.
+func OpenByteSource(filename string) (ByteSource, error) {
+	if filename == "-" {
+		return newPagedSource(os.Stdin), nil
+	}
+	r, err := mmap.Open(filename)
+	if err != nil {
+		f, ferr := os.Open(filename)
+		if ferr != nil {
+			return nil, err
+		}
+		return newPagedSource(f), nil
+	}
+	return &mmapSource{r: r}, nil
+}
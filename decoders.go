@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"io"
+
+	"github.com/gdamore/tcell"
+)
+
+// Region describes a structurally meaningful byte range detected by a
+// Decoder. It's used to overlay boundaries in the byte grid and to
+// label the status bar when the view is sitting inside it.
+type Region struct {
+	Offset int64
+	Length int64
+	Name   string
+	Color  tcell.Color
+}
+
+// Decoder recognizes a structured file format and describes the byte
+// regions it's made of. Decoders are sniffed against the loaded file at
+// startup by checking the leading magic bytes, the same way gcexportdata
+// dispatches on the first byte of its export data to pick an importer.
+// Detect does not parse the header beyond those magic bytes.
+type Decoder interface {
+	// Detect reports whether dat looks like this decoder's format.
+	Detect(dat []byte) bool
+	// Regions returns the structural byte ranges found in the file.
+	Regions(r io.ReaderAt, size int64) []Region
+	// Describe returns a short label for the region at offset, or ""
+	// if offset doesn't fall inside anything this decoder recognizes.
+	Describe(offset int64) string
+}
+
+// decoderRegions is embedded by concrete decoders to provide a shared
+// Describe implementation over whatever regions the last Regions() call
+// found.
+type decoderRegions struct {
+	regions []Region
+}
+
+func (d *decoderRegions) Describe(offset int64) string {
+	for _, r := range d.regions {
+		if offset >= r.Offset && offset < r.Offset+r.Length {
+			return r.Name
+		}
+	}
+	return ""
+}
+
+// decoderRegistry holds every decoder binx knows about, in registration
+// order.
+var decoderRegistry []Decoder
+
+// RegisterDecoder adds d to the set of decoders sniffed against a
+// loaded file. Third parties can call this from an init() function to
+// teach binx new formats without touching binx internals.
+func RegisterDecoder(d Decoder) {
+	decoderRegistry = append(decoderRegistry, d)
+}
+
+func init() {
+	RegisterDecoder(&elfDecoder{})
+	RegisterDecoder(&peDecoder{})
+	RegisterDecoder(&machoDecoder{})
+	RegisterDecoder(&zipDecoder{})
+	RegisterDecoder(&pngDecoder{})
+	RegisterDecoder(&gzipDecoder{})
+}
+
+// detectDecoders returns every registered decoder whose Detect matches
+// dat, in registration order.
+func detectDecoders(dat []byte) []Decoder {
+	var matched []Decoder
+	for _, d := range decoderRegistry {
+		if d.Detect(dat) {
+			matched = append(matched, d)
+		}
+	}
+	return matched
+}
+
+// readRegion returns length trimmed so that [offset, offset+length)
+// ends at size, and never below zero. It does not read from r.
+func readRegion(r io.ReaderAt, offset, length, size int64) int64 {
+	if offset+length > size {
+		length = size - offset
+	}
+	if length < 0 {
+		length = 0
+	}
+	return length
+}
+
+// elfDecoder recognizes ELF executables and object files.
+type elfDecoder struct{ decoderRegions }
+
+var elfMagic = []byte{0x7f, 'E', 'L', 'F'}
+
+func (d *elfDecoder) Detect(dat []byte) bool {
+	return len(dat) >= 4 && bytes.Equal(dat[:4], elfMagic)
+}
+
+// elfHeaderLen is the ELF header length for 32-bit and 64-bit files,
+// keyed by elf.Class.
+var elfHeaderLen = map[elf.Class]int64{elf.ELFCLASS32: 52, elf.ELFCLASS64: 64}
+
+// Regions walks the ELF section header table via debug/elf, so the
+// header is followed by one region per section instead of a single
+// catch-all body.
+func (d *elfDecoder) Regions(r io.ReaderAt, size int64) []Region {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		hdrLen := readRegion(r, 0, 64, size)
+		d.regions = []Region{
+			{Offset: 0, Length: hdrLen, Name: "ELF header", Color: tcell.ColorGreen},
+			{Offset: hdrLen, Length: size - hdrLen, Name: "ELF body", Color: tcell.ColorDarkGreen},
+		}
+		return d.regions
+	}
+	defer f.Close()
+
+	hdrLen := elfHeaderLen[f.Class]
+	regions := []Region{{Offset: 0, Length: readRegion(r, 0, hdrLen, size), Name: "ELF header", Color: tcell.ColorGreen}}
+	for _, sec := range f.Sections {
+		if sec.Type == elf.SHT_NULL || sec.Type == elf.SHT_NOBITS || sec.Size == 0 || sec.Offset > uint64(size) {
+			continue
+		}
+		regions = append(regions, Region{
+			Offset: int64(sec.Offset),
+			Length: readRegion(r, int64(sec.Offset), int64(sec.Size), size),
+			Name:   "ELF section " + sec.Name,
+			Color:  tcell.ColorDarkGreen,
+		})
+	}
+	d.regions = regions
+	return d.regions
+}
+
+// peDecoder recognizes PE/COFF (Windows) executables via their leading
+// "MZ" DOS stub magic.
+type peDecoder struct{ decoderRegions }
+
+func (d *peDecoder) Detect(dat []byte) bool {
+	return len(dat) >= 2 && dat[0] == 'M' && dat[1] == 'Z'
+}
+
+func (d *peDecoder) Regions(r io.ReaderAt, size int64) []Region {
+	hdrLen := readRegion(r, 0, 64, size)
+	d.regions = []Region{
+		{Offset: 0, Length: hdrLen, Name: "DOS/PE header", Color: tcell.ColorBlue},
+		{Offset: hdrLen, Length: size - hdrLen, Name: "PE body", Color: tcell.ColorDarkBlue},
+	}
+	return d.regions
+}
+
+// machoDecoder recognizes Mach-O executables via their 32/64-bit,
+// either-endian magic numbers.
+type machoDecoder struct{ decoderRegions }
+
+func (d *machoDecoder) Detect(dat []byte) bool {
+	if len(dat) < 4 {
+		return false
+	}
+	switch string(dat[:4]) {
+	case "\xfe\xed\xfa\xce", "\xfe\xed\xfa\xcf", "\xce\xfa\xed\xfe", "\xcf\xfa\xed\xfe",
+		"\xca\xfe\xba\xbe", "\xbe\xba\xfe\xca":
+		return true
+	}
+	return false
+}
+
+func (d *machoDecoder) Regions(r io.ReaderAt, size int64) []Region {
+	hdrLen := readRegion(r, 0, 32, size)
+	d.regions = []Region{
+		{Offset: 0, Length: hdrLen, Name: "Mach-O header", Color: tcell.ColorPurple},
+		{Offset: hdrLen, Length: size - hdrLen, Name: "Mach-O body", Color: tcell.ColorDarkMagenta},
+	}
+	return d.regions
+}
+
+// zipDecoder recognizes ZIP archives and JAR files, which share the
+// same local-file-header magic.
+type zipDecoder struct{ decoderRegions }
+
+func (d *zipDecoder) Detect(dat []byte) bool {
+	return len(dat) >= 4 && dat[0] == 'P' && dat[1] == 'K' &&
+		(dat[2] == 0x03 || dat[2] == 0x05) && (dat[3] == 0x04 || dat[3] == 0x06)
+}
+
+func (d *zipDecoder) Regions(r io.ReaderAt, size int64) []Region {
+	hdrLen := readRegion(r, 0, 30, size)
+	d.regions = []Region{
+		{Offset: 0, Length: hdrLen, Name: "ZIP local file header", Color: tcell.ColorYellow},
+		{Offset: hdrLen, Length: size - hdrLen, Name: "ZIP body", Color: tcell.ColorOlive},
+	}
+	return d.regions
+}
+
+// pngDecoder recognizes PNG images via their 8-byte signature.
+type pngDecoder struct{ decoderRegions }
+
+var pngMagic = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+func (d *pngDecoder) Detect(dat []byte) bool {
+	return len(dat) >= 8 && bytes.Equal(dat[:8], pngMagic)
+}
+
+// pngChunkColor picks a region color by chunk type, so the critical
+// chunks (IHDR/IDAT/IEND) stand out from ancillary ones at a glance.
+func pngChunkColor(typ string) tcell.Color {
+	switch typ {
+	case "IHDR", "IEND":
+		return tcell.ColorFuchsia
+	case "IDAT":
+		return tcell.ColorPink
+	default:
+		return tcell.ColorOlive
+	}
+}
+
+// Regions walks the PNG chunk stream, reading each chunk's 8-byte
+// length+type header via r so the signature is followed by one region
+// per chunk instead of a single catch-all body.
+func (d *pngDecoder) Regions(r io.ReaderAt, size int64) []Region {
+	regions := []Region{{Offset: 0, Length: readRegion(r, 0, 8, size), Name: "PNG signature", Color: tcell.ColorFuchsia}}
+
+	var hdr [8]byte
+	for offset := int64(8); offset+8 <= size; {
+		if _, err := r.ReadAt(hdr[:], offset); err != nil {
+			break
+		}
+		dataLen := int64(binary.BigEndian.Uint32(hdr[0:4]))
+		typ := string(hdr[4:8])
+		chunkLen := readRegion(r, offset, 8+dataLen+4, size)
+		regions = append(regions, Region{Offset: offset, Length: chunkLen, Name: "PNG " + typ, Color: pngChunkColor(typ)})
+		offset += chunkLen
+		if typ == "IEND" {
+			break
+		}
+	}
+	d.regions = regions
+	return d.regions
+}
+
+// gzipDecoder recognizes gzip streams via their 2-byte magic.
+type gzipDecoder struct{ decoderRegions }
+
+func (d *gzipDecoder) Detect(dat []byte) bool {
+	return len(dat) >= 2 && dat[0] == 0x1f && dat[1] == 0x8b
+}
+
+func (d *gzipDecoder) Regions(r io.ReaderAt, size int64) []Region {
+	hdrLen := readRegion(r, 0, 10, size)
+	d.regions = []Region{
+		{Offset: 0, Length: hdrLen, Name: "gzip header", Color: tcell.ColorRed},
+		{Offset: hdrLen, Length: size - hdrLen, Name: "gzip body", Color: tcell.ColorDarkRed},
+	}
+	return d.regions
+}
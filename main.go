@@ -1,18 +1,20 @@
 package main
 
 import (
-	"bytes"
-	"encoding/hex"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"math"
 	"os"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/mattn/go-runewidth"
 
 	"github.com/gdamore/tcell"
+
+	"github.com/deorbit/binx/script"
+	"github.com/deorbit/binx/session"
 )
 
 // Input modes
@@ -20,8 +22,34 @@ const (
 	NormalMode = iota
 	SeekInputMode
 	PatternInputMode
+	ScriptInputMode
+	MarkSetMode
+	MarkJumpMode
+	AnnotateInputMode
+)
+
+// annotateColor is the highlight color given to annotations the user
+// creates interactively with BinxKeyA.
+const annotateColor = tcell.ColorOrange
+
+// autosaveDelay is how long Reduce waits after the last action before
+// writing the session to disk, so a burst of scrolling or searching
+// collapses into a single write once things settle.
+const autosaveDelay = 2 * time.Second
+
+// Visualization modes control how the byte grid lays out and colors
+// the bytes currently in view.
+const (
+	LinearVisMode = iota
+	HilbertVisMode
+	EntropyVisMode
+	numVisModes
 )
 
+// entropyWindowSize is the width, in bytes, of the rolling window used
+// to compute the Shannon entropy shown by EntropyVisMode.
+const entropyWindowSize = 256
+
 // Actions
 const (
 	BinxResize         = "BINX_RESIZE"
@@ -34,6 +62,15 @@ const (
 	BinxKeyS           = "BINX_KEY_S"
 	BinxKeyF           = "BINX_KEY_F"
 	BinxKeyOther       = "BINX_KEY_OTHER"
+	BinxCycleVisMode   = "BINX_CYCLE_VISMODE"
+	BinxKeyD           = "BINX_KEY_D"
+	BinxKeyN           = "BINX_KEY_N"
+	BinxKeyPrevN       = "BINX_KEY_SHIFT_N"
+	BinxKeyColon       = "BINX_KEY_COLON"
+	BinxSetMark        = "BINX_SET_MARK"
+	BinxJumpMark       = "BINX_JUMP_MARK"
+	BinxAutosave       = "BINX_AUTOSAVE"
+	BinxKeyA           = "BINX_KEY_A"
 )
 
 // binxConfig holds binx configuration data
@@ -53,22 +90,50 @@ type binxConfig struct {
 }
 
 type AppState struct {
-	mutex           sync.Mutex
-	filename        string
-	dat             []byte
-	screen          tcell.Screen
-	byteVisWidth    int
-	byteVisHeight   int
-	statStyle       tcell.Style
-	byteStyle       tcell.Style
-	alertStyle      tcell.Style
-	startByte       int64
-	mode            int
-	userInput       string
-	highlightPos    int64
-	highlightLength int64
-	lastAction      string
-	status          string
+	mutex         sync.Mutex
+	filename      string
+	source        ByteSource
+	fileSize      int64
+	screen        tcell.Screen
+	byteVisWidth  int
+	byteVisHeight int
+	statStyle     tcell.Style
+	byteStyle     tcell.Style
+	alertStyle    tcell.Style
+	startByte     int64
+	mode          int
+	visMode       int
+	userInput     string
+	highlights    []int64
+	highlightIdx  int
+	lastAction    string
+	status        string
+
+	// hilbertTable caches the cell-index -> (x, y) mapping used by
+	// HilbertVisMode, keyed by the viewport size it was built for so it
+	// only needs to be recomputed on resize.
+	hilbertTable  []point
+	hilbertTableW int
+	hilbertTableH int
+
+	// matchedDecoders holds every registered Decoder whose Detect
+	// matched the loaded file; decoderIdx selects which of them is
+	// currently overlaid, cycled with BinxKeyD.
+	matchedDecoders []Decoder
+	decoderIdx      int
+	regions         []Region
+
+	// sess holds the bookmarks, annotations, search history, and last
+	// position persisted across runs; sessStore/sessKey say where it's
+	// saved back to on the autosave timer.
+	sess      *session.Session
+	sessStore *session.Store
+	sessKey   string
+}
+
+// point is a single (x, y) coordinate in the byte grid.
+type point struct {
+	x, y int
 }
 
 type Action struct {
@@ -87,12 +152,25 @@ func CreateStore(rootReducer func(Action) *AppState) Store {
 }
 
 // Reduce waits for events on the dispatcher channel then runs them
-// through the user-defined reducer to update app state.
+// through the user-defined reducer to update app state. Every action
+// other than the autosave action itself resets a debounce timer that,
+// once it settles, feeds an autosave action back through the same
+// dispatcher.
 func (s *Store) Reduce() {
+	var saveTimer *time.Timer
 	for {
 		select {
 		case action := <-s.Dispatcher:
 			s.reducer(action)
+			if action.name == BinxAutosave {
+				continue
+			}
+			if saveTimer != nil {
+				saveTimer.Stop()
+			}
+			saveTimer = time.AfterFunc(autosaveDelay, func() {
+				s.Dispatcher <- Action{name: BinxAutosave}
+			})
 		}
 	}
 }
@@ -116,6 +194,22 @@ func HandleTcellEvent(store Store, ev tcell.Event) {
 			store.Dispatcher <- Action{name: BinxKeyS, value: ev.Rune()}
 		} else if ev.Rune() == 'f' {
 			store.Dispatcher <- Action{name: BinxKeyF, value: ev.Rune()}
+		} else if ev.Rune() == 'v' {
+			store.Dispatcher <- Action{name: BinxCycleVisMode, value: ev.Rune()}
+		} else if ev.Rune() == 'd' {
+			store.Dispatcher <- Action{name: BinxKeyD, value: ev.Rune()}
+		} else if ev.Rune() == 'n' {
+			store.Dispatcher <- Action{name: BinxKeyN, value: ev.Rune()}
+		} else if ev.Rune() == 'N' {
+			store.Dispatcher <- Action{name: BinxKeyPrevN, value: ev.Rune()}
+		} else if ev.Rune() == ':' {
+			store.Dispatcher <- Action{name: BinxKeyColon, value: ev.Rune()}
+		} else if ev.Rune() == 'm' {
+			store.Dispatcher <- Action{name: BinxSetMark, value: ev.Rune()}
+		} else if ev.Rune() == '\'' {
+			store.Dispatcher <- Action{name: BinxJumpMark, value: ev.Rune()}
+		} else if ev.Rune() == 'a' {
+			store.Dispatcher <- Action{name: BinxKeyA, value: ev.Rune()}
 		} else {
 			store.Dispatcher <- Action{name: BinxKeyOther, value: ev.Rune()}
 		}
@@ -141,22 +235,173 @@ func emitStr(s tcell.Screen, x, y int, style tcell.Style, str string) {
 func emitStatBar(state *AppState) {
 	w, h := state.screen.Size()
 	numVisibleBytes := w * h
+	regionLabel := ""
+	if len(state.matchedDecoders) > 0 {
+		d := state.matchedDecoders[state.decoderIdx]
+		if desc := d.Describe(state.startByte); desc != "" {
+			regionLabel = fmt.Sprintf("--%s", desc)
+		}
+	}
 	emitStr(state.screen,
 		0, h-1, state.statStyle,
-		fmt.Sprintf("--%d--%d--%d--Last Action: %s--Status: %s...\t%s", state.startByte, state.startByte+int64(numVisibleBytes), state.mode, state.lastAction, state.status, state.userInput))
+		fmt.Sprintf("--%d--%d--%d--Last Action: %s--Status: %s%s...\t%s", state.startByte, state.startByte+int64(numVisibleBytes), state.mode, state.lastAction, state.status, regionLabel, state.userInput))
 }
 
-// findPattern searches buf for a byte pattern specified by the hex
-// string p.
-func findBytePattern(p string, buf []byte) (int64, error) {
-	decodedPattern, err := hex.DecodeString(p)
-	if err != nil {
-		return 0, err
+// clampStartByte keeps b within [0, fileSize].
+func clampStartByte(b, fileSize int64) int64 {
+	if b < 0 {
+		return 0
+	}
+	if b > fileSize {
+		return fileSize
+	}
+	return b
+}
+
+// alignToRow rounds offset down to the start of the grid row it falls
+// on, so paging a hit into view lines the grid up the same way it
+// always does.
+func alignToRow(offset, width int64) int64 {
+	if width <= 0 {
+		return offset
+	}
+	return offset - offset%width
+}
+
+// setMark records name as a bookmark at the current view position.
+func setMark(state *AppState, name rune) {
+	letter := string(name)
+	state.sess.SetMark(letter, state.startByte)
+	state.status = fmt.Sprintf("mark %s set", letter)
+}
+
+// jumpMark moves the view to the bookmark named name, if one exists.
+func jumpMark(state *AppState, name rune) {
+	letter := string(name)
+	offset, ok := state.sess.Mark(letter)
+	if !ok {
+		state.status = fmt.Sprintf("no mark %s", letter)
+		return
+	}
+	state.startByte = clampStartByte(offset, state.fileSize)
+	state.status = fmt.Sprintf("jumped to mark %s", letter)
+}
+
+// regionAt returns the region containing offset, or nil if none does.
+func regionAt(regions []Region, offset int64) *Region {
+	for i := range regions {
+		if offset >= regions[i].Offset && offset < regions[i].Offset+regions[i].Length {
+			return &regions[i]
+		}
+	}
+	return nil
+}
+
+// nextPow2 returns the smallest power of two >= n.
+func nextPow2(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
+
+// d2xy converts a distance d along a Hilbert curve of side n (n must be
+// a power of two) into (x, y) coordinates, following the standard
+// curve-construction algorithm.
+func d2xy(n, d int) (int, int) {
+	x, y := 0, 0
+	for s := 1; s < n; s *= 2 {
+		rx := 1 & (d / 2)
+		ry := 1 & (d ^ rx)
+		x, y = hilbertRot(s, x, y, rx, ry)
+		x += s * rx
+		y += s * ry
+		d /= 4
+	}
+	return x, y
+}
+
+// hilbertRot rotates and flips the quadrant (x, y) belongs to so the
+// curve stays contiguous across quadrant boundaries.
+func hilbertRot(s, x, y, rx, ry int) (int, int) {
+	if ry == 0 {
+		if rx == 1 {
+			x = s - 1 - x
+			y = s - 1 - y
+		}
+		x, y = y, x
 	}
+	return x, y
+}
 
-	loc := bytes.Index(buf, decodedPattern)
+// buildHilbertTable walks a Hilbert curve over a square whose side is the
+// smallest power of two >= w*h, keeping only the points that land inside
+// the w x h viewport, in curve order. The result maps a cell's linear
+// index (the order bytes are read off the file) to its (x, y) position
+// in the grid.
+func buildHilbertTable(w, h int) []point {
+	want := w * h
+	table := make([]point, 0, want)
+	if want <= 0 {
+		return table
+	}
+	n := nextPow2(want)
+	for d := 0; d < n*n && len(table) < want; d++ {
+		x, y := d2xy(n, d)
+		if x < w && y < h {
+			table = append(table, point{x, y})
+		}
+	}
+	return table
+}
 
-	return int64(loc), nil
+// hilbertPositions returns the cached cell-index -> (x, y) table for a
+// w x h viewport, rebuilding it if the viewport size has changed.
+func hilbertPositions(state *AppState, w, h int) []point {
+	if state.hilbertTable == nil || state.hilbertTableW != w || state.hilbertTableH != h {
+		state.hilbertTable = buildHilbertTable(w, h)
+		state.hilbertTableW = w
+		state.hilbertTableH = h
+	}
+	return state.hilbertTable
+}
+
+// shannonEntropy returns the Shannon entropy, in bits, of buf.
+func shannonEntropy(buf []byte) float64 {
+	if len(buf) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, b := range buf {
+		counts[b]++
+	}
+	entropy := 0.0
+	total := float64(len(buf))
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / total
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// entropyColor maps an entropy value in [0, 8] bits to a color running
+// from cool blue (low entropy, plaintext-like) to hot red (high entropy,
+// compressed or encrypted regions).
+func entropyColor(entropy float64) tcell.Color {
+	frac := entropy / 8.0
+	if frac < 0 {
+		frac = 0
+	}
+	if frac > 1 {
+		frac = 1
+	}
+	r := int32(frac * 255)
+	b := int32((1 - frac) * 255)
+	return tcell.NewRGBColor(r, 0, b)
 }
 
 // rootReducer is a closure around the state object. It returns
@@ -176,7 +421,7 @@ func rootReducer(state *AppState) func(Action) *AppState {
 				state.startByte = 0
 			}
 		case BinxKeyDown:
-			state.startByte += int64(state.byteVisWidth)
+			state.startByte = clampStartByte(state.startByte+int64(state.byteVisWidth), state.fileSize)
 		case BinxResize:
 			_, h := state.screen.Size()
 			state.byteVisHeight = h - 1
@@ -193,25 +438,103 @@ func rootReducer(state *AppState) func(Action) *AppState {
 				state.mode = NormalMode
 				state.userInput = ""
 			}
+			if state.mode == ScriptInputMode {
+				state.mode = NormalMode
+				state.userInput = ""
+			}
+			if state.mode == AnnotateInputMode {
+				state.mode = NormalMode
+				state.userInput = ""
+			}
+			if state.mode == MarkSetMode || state.mode == MarkJumpMode {
+				state.mode = NormalMode
+			}
 		case BinxKeyEnter:
 			if state.mode == SeekInputMode {
 				startByte, err := strconv.ParseInt(state.userInput, 0, 64) // hex, dec, or octal
-				state.startByte = startByte
+				state.startByte = clampStartByte(startByte, state.fileSize)
 				state.userInput = ""
 				state.mode = NormalMode
 				if err != nil {
 					break
 				}
 			} else if state.mode == PatternInputMode {
-				highlightPos, err := findBytePattern(state.userInput, state.dat)
+				query, err := ParseQuery(state.userInput)
+				if err != nil {
+					state.status = err.Error()
+					state.userInput = ""
+					state.mode = NormalMode
+					break
+				}
+				state.sess.AddHistory(state.userInput)
+				state.highlights = query.FindAllInSource(state.source, state.fileSize)
+				state.highlightIdx = 0
+				state.status = fmt.Sprintf("%d hits", len(state.highlights))
+				if len(state.highlights) > 0 {
+					state.startByte = alignToRow(state.highlights[0], int64(state.byteVisWidth))
+				}
+				state.userInput = ""
+				state.mode = NormalMode
+			} else if state.mode == ScriptInputMode {
+				src := state.userInput
+				state.userInput = ""
+				state.mode = NormalMode
+				prog, err := script.Assemble(src)
 				if err != nil {
 					state.status = err.Error()
 					break
 				}
-				state.highlightPos = highlightPos
-				state.status = fmt.Sprintf("%d", highlightPos)
+				state.mutex.Unlock()
+				err = script.NewVM().Run(prog, &scriptDispatcher{state: state})
+				state.mutex.Lock()
+				if err != nil {
+					state.status = err.Error()
+				} else {
+					state.status = "script ok"
+				}
+			} else if state.mode == AnnotateInputMode {
+				comment := state.userInput
 				state.userInput = ""
 				state.mode = NormalMode
+				state.sess.Annotate(state.startByte, int64(state.byteVisWidth), int32(annotateColor), comment)
+				state.status = "annotation added"
+			}
+		case BinxCycleVisMode:
+			if state.mode == NormalMode {
+				state.visMode = (state.visMode + 1) % numVisModes
+			} else {
+				state.userInput += string(action.value.(rune))
+			}
+		case BinxKeyD:
+			if state.mode == NormalMode {
+				if len(state.matchedDecoders) > 0 {
+					state.decoderIdx = (state.decoderIdx + 1) % len(state.matchedDecoders)
+					d := state.matchedDecoders[state.decoderIdx]
+					state.regions = d.Regions(state.source, state.fileSize)
+				}
+			} else {
+				state.userInput += string(action.value.(rune))
+			}
+		case BinxKeyN:
+			if state.mode == NormalMode {
+				if len(state.highlights) > 0 {
+					state.highlightIdx = (state.highlightIdx + 1) % len(state.highlights)
+					state.startByte = alignToRow(state.highlights[state.highlightIdx], int64(state.byteVisWidth))
+				}
+			} else {
+				state.userInput += string(action.value.(rune))
+			}
+		case BinxKeyPrevN:
+			if state.mode == NormalMode {
+				if len(state.highlights) > 0 {
+					state.highlightIdx--
+					if state.highlightIdx < 0 {
+						state.highlightIdx = len(state.highlights) - 1
+					}
+					state.startByte = alignToRow(state.highlights[state.highlightIdx], int64(state.byteVisWidth))
+				}
+			} else {
+				state.userInput += string(action.value.(rune))
 			}
 		case BinxSetScreenStyle:
 			state.screen.SetStyle(action.value.(tcell.Style))
@@ -229,9 +552,55 @@ func rootReducer(state *AppState) func(Action) *AppState {
 			} else {
 				state.userInput += string(action.value.(rune))
 			}
+		case BinxKeyColon:
+			if state.mode == NormalMode {
+				state.mode = ScriptInputMode
+				state.userInput = ""
+			} else {
+				state.userInput += string(action.value.(rune))
+			}
 		case BinxKeyOther:
 			if state.mode == SeekInputMode {
 				state.userInput += string(action.value.(rune))
+			} else if state.mode == MarkSetMode {
+				setMark(state, action.value.(rune))
+				state.mode = NormalMode
+			} else if state.mode == MarkJumpMode {
+				jumpMark(state, action.value.(rune))
+				state.mode = NormalMode
+			}
+		case BinxSetMark:
+			if state.mode == NormalMode {
+				state.mode = MarkSetMode
+			} else if state.mode == MarkSetMode {
+				setMark(state, action.value.(rune))
+				state.mode = NormalMode
+			} else {
+				state.userInput += string(action.value.(rune))
+			}
+		case BinxJumpMark:
+			if state.mode == NormalMode {
+				state.mode = MarkJumpMode
+			} else if state.mode == MarkJumpMode {
+				jumpMark(state, action.value.(rune))
+				state.mode = NormalMode
+			} else {
+				state.userInput += string(action.value.(rune))
+			}
+		case BinxKeyA:
+			if state.mode == NormalMode {
+				state.mode = AnnotateInputMode
+				state.userInput = ""
+			} else {
+				state.userInput += string(action.value.(rune))
+			}
+		case BinxAutosave:
+			if state.sessStore != nil {
+				state.sess.StartByte = state.startByte
+				store, key, sess := state.sessStore, state.sessKey, state.sess
+				state.mutex.Unlock()
+				store.Save(key, sess)
+				state.mutex.Lock()
 			}
 		default:
 			break
@@ -250,9 +619,63 @@ func render(state *AppState) {
 	if numVisibleBytes < 0 {
 		numVisibleBytes = 0
 	}
-	for i, b := range state.dat[state.startByte : state.startByte+int64(numVisibleBytes)] {
-		state.byteStyle = state.byteStyle.Foreground(tcell.Color(b))
-		state.screen.SetContent(i%w, i/w, tcell.RuneBoard, nil, state.byteStyle)
+	remaining := state.fileSize - state.startByte
+	if remaining < 0 {
+		remaining = 0
+	}
+	if int64(numVisibleBytes) > remaining {
+		numVisibleBytes = int(remaining)
+	}
+	window := make([]byte, numVisibleBytes)
+	n, _ := state.source.ReadAt(window, state.startByte)
+	window = window[:n]
+	regionStyle := func(i int, style tcell.Style) tcell.Style {
+		offset := state.startByte + int64(i)
+		if r := regionAt(state.regions, offset); r != nil {
+			style = style.Background(r.Color)
+		}
+		if a := state.sess.AnnotationAt(offset); a != nil {
+			style = style.Underline(true)
+			if regionAt(state.regions, offset) == nil {
+				style = style.Background(tcell.Color(a.Color))
+			}
+		}
+		return style
+	}
+	switch state.visMode {
+	case HilbertVisMode:
+		positions := hilbertPositions(state, w, h)
+		for i, b := range window {
+			if i >= len(positions) {
+				break
+			}
+			style := regionStyle(i, state.byteStyle.Foreground(tcell.Color(b)))
+			p := positions[i]
+			state.screen.SetContent(p.x, p.y, tcell.RuneBoard, nil, style)
+		}
+	case EntropyVisMode:
+		for i := range window {
+			lo := i - entropyWindowSize/2
+			if lo < 0 {
+				lo = 0
+			}
+			hi := lo + entropyWindowSize
+			if hi > len(window) {
+				hi = len(window)
+				lo = hi - entropyWindowSize
+				if lo < 0 {
+					lo = 0
+				}
+			}
+			entropy := shannonEntropy(window[lo:hi])
+			style := regionStyle(i, state.byteStyle.Foreground(entropyColor(entropy)))
+			state.screen.SetContent(i%w, i/w, tcell.RuneBoard, nil, style)
+		}
+	default:
+		for i, b := range window {
+			style := regionStyle(i, state.byteStyle.Foreground(tcell.Color(b)))
+			state.screen.SetContent(i%w, i/w, tcell.RuneBoard, nil, style)
+		}
 	}
 	emitStatBar(state)
 	state.mutex.Unlock()
@@ -261,6 +684,9 @@ func render(state *AppState) {
 
 func main() {
 	filename := flag.String("f", "", "Name of file to view.")
+	scriptFile := flag.String("s", "", "Path to a binx script (.bx) to run on startup.")
+	exportSession := flag.String("export-session", "", "Dump the file's saved session as portable JSON to this path, then exit.")
+	importSession := flag.String("import-session", "", "Load session state (bookmarks, annotations, history) from a portable JSON file exported with -export-session.")
 	flag.Parse()
 
 	if *filename == "" {
@@ -268,13 +694,41 @@ func main() {
 		os.Exit(1)
 	}
 
-	dat, err := ioutil.ReadFile(*filename)
+	source, err := OpenByteSource(*filename)
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 
+	// A missing or unwritable session store shouldn't keep binx from
+	// opening the file; fall back to a fresh, unpersisted Session and
+	// carry on.
+	sess, sessStore, sessKey := session.New(), (*session.Store)(nil), ""
+	if key, err := session.Fingerprint(source, source.Len()); err != nil {
+		fmt.Fprintf(os.Stderr, "session: %v\n", err)
+	} else if store, err := session.NewStore(session.DefaultDir()); err != nil {
+		fmt.Fprintf(os.Stderr, "session: %v\n", err)
+	} else if loaded, err := store.Load(key); err != nil {
+		fmt.Fprintf(os.Stderr, "session: %v\n", err)
+	} else {
+		sess, sessStore, sessKey = loaded, store, key
+	}
+	if *importSession != "" {
+		sess, err = session.Import(*importSession)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *exportSession != "" {
+		if err := session.Export(*exportSession, sess); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	tcell.SetEncodingFallback(tcell.EncodingFallbackASCII)
 	s, err := tcell.NewScreen()
 	if err != nil {
@@ -283,9 +737,11 @@ func main() {
 	}
 	s.Init()
 	_, termHeight := s.Size()
+	fileSize := source.Len()
 	state := AppState{
 		filename:      *filename,
-		dat:           dat,
+		source:        source,
+		fileSize:      fileSize,
 		screen:        s,
 		byteVisWidth:  80,
 		byteVisHeight: termHeight - 1,
@@ -294,8 +750,35 @@ func main() {
 			Background(tcell.ColorBlack),
 		statStyle:  tcell.StyleDefault,
 		alertStyle: tcell.StyleDefault,
-		startByte:  0,
+		startByte:  clampStartByte(sess.StartByte, fileSize),
 		mode:       NormalMode,
+		sess:       sess,
+		sessStore:  sessStore,
+		sessKey:    sessKey,
+	}
+
+	header := make([]byte, 64)
+	n, _ := source.ReadAt(header, 0)
+	state.matchedDecoders = detectDecoders(header[:n])
+	if len(state.matchedDecoders) > 0 {
+		state.regions = state.matchedDecoders[0].Regions(source, fileSize)
+	}
+
+	if *scriptFile != "" {
+		src, err := os.ReadFile(*scriptFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		prog, err := script.Assemble(string(src))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if err := script.NewVM().Run(prog, &scriptDispatcher{state: &state}); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	store := CreateStore(rootReducer(&state))
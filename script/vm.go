@@ -0,0 +1,269 @@
+// Package script implements a tiny stack-based bytecode VM that lets a
+// binx script describe a repeatable walk through a binary file: seek
+// around, search for patterns, mark byte ranges, and branch on whether
+// a search succeeded. Scripts are assembled from a small text assembly
+// language and run against a Dispatcher supplied by the host.
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Op identifies a VM instruction.
+type Op int
+
+const (
+	OpPush Op = iota
+	OpSeek
+	OpFind
+	OpFindNext
+	OpMark
+	OpLabel
+	OpJmp
+	OpJz
+	OpHalt
+)
+
+// Instruction is one decoded line of a script program.
+type Instruction struct {
+	Op     Op
+	IntArg int64
+	StrArg string
+	IsStr  bool // for OpPush: whether to push StrArg instead of IntArg
+}
+
+// Program is an assembled script: a flat instruction list plus the
+// label -> instruction-index table used by Jmp and Jz.
+type Program struct {
+	Instructions []Instruction
+	Labels       map[string]int
+}
+
+// Dispatcher is how a running Program reaches outside itself. The VM
+// drives the host purely through these calls so this package never
+// needs to know how the host represents its state.
+type Dispatcher interface {
+	// Seek moves the view to offset.
+	Seek(offset int64)
+	// Find searches for pattern from the start of the file and reports
+	// the offset of the first hit, if any.
+	Find(pattern string) (offset int64, found bool)
+	// FindNext repeats the last Find starting after the current
+	// position, reporting the offset of the next hit, if any.
+	FindNext() (offset int64, found bool)
+	// AddHighlight marks a byte range of the given length starting at
+	// the current position.
+	AddHighlight(length int64)
+}
+
+// Assemble parses binx script assembly - one instruction per line, "#"
+// starts a line comment - into a runnable Program.
+func Assemble(src string) (*Program, error) {
+	prog := &Program{Labels: map[string]int{}}
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 2)
+		mnemonic := strings.ToUpper(fields[0])
+		arg := ""
+		if len(fields) > 1 {
+			arg = strings.TrimSpace(fields[1])
+		}
+
+		inst, err := assembleLine(mnemonic, arg)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		if inst.Op == OpLabel {
+			prog.Labels[inst.StrArg] = len(prog.Instructions)
+		}
+		prog.Instructions = append(prog.Instructions, inst)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return prog, nil
+}
+
+func assembleLine(mnemonic, arg string) (Instruction, error) {
+	switch mnemonic {
+	case "PUSH":
+		if n, err := strconv.ParseInt(arg, 0, 64); err == nil {
+			return Instruction{Op: OpPush, IntArg: n}, nil
+		}
+		s, err := unquote(arg)
+		if err != nil {
+			return Instruction{}, fmt.Errorf("PUSH expects an integer or a quoted string, got %q", arg)
+		}
+		return Instruction{Op: OpPush, StrArg: s, IsStr: true}, nil
+	case "SEEK":
+		return Instruction{Op: OpSeek}, nil
+	case "FIND":
+		return Instruction{Op: OpFind}, nil
+	case "FINDNEXT":
+		return Instruction{Op: OpFindNext}, nil
+	case "MARK":
+		n, err := strconv.ParseInt(arg, 0, 64)
+		if err != nil {
+			return Instruction{}, fmt.Errorf("MARK expects a length, got %q", arg)
+		}
+		return Instruction{Op: OpMark, IntArg: n}, nil
+	case "LABEL":
+		if arg == "" {
+			return Instruction{}, fmt.Errorf("LABEL requires a name")
+		}
+		return Instruction{Op: OpLabel, StrArg: arg}, nil
+	case "JMP":
+		if arg == "" {
+			return Instruction{}, fmt.Errorf("JMP requires a label name")
+		}
+		return Instruction{Op: OpJmp, StrArg: arg}, nil
+	case "JZ":
+		if arg == "" {
+			return Instruction{}, fmt.Errorf("JZ requires a label name")
+		}
+		return Instruction{Op: OpJz, StrArg: arg}, nil
+	case "HALT":
+		return Instruction{Op: OpHalt}, nil
+	}
+	return Instruction{}, fmt.Errorf("unknown instruction %q", mnemonic)
+}
+
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("expected a quoted string, got %q", s)
+	}
+	return s[1 : len(s)-1], nil
+}
+
+// VM executes an assembled Program against a Dispatcher, threading a
+// small value stack and a symbol table of offsets the program has
+// named with LABEL.
+type VM struct {
+	Symbols map[string]int64
+}
+
+// NewVM returns a VM with an empty symbol table.
+func NewVM() *VM {
+	return &VM{Symbols: map[string]int64{}}
+}
+
+// Run executes prog from its first instruction until it HALTs or runs
+// off the end of the program.
+func (vm *VM) Run(prog *Program, d Dispatcher) error {
+	var stack []interface{}
+
+	pop := func() (interface{}, error) {
+		if len(stack) == 0 {
+			return nil, fmt.Errorf("stack underflow")
+		}
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v, nil
+	}
+	popInt := func() (int64, error) {
+		v, err := pop()
+		if err != nil {
+			return 0, err
+		}
+		n, ok := v.(int64)
+		if !ok {
+			return 0, fmt.Errorf("expected an integer on the stack, got %v", v)
+		}
+		return n, nil
+	}
+	popStr := func() (string, error) {
+		v, err := pop()
+		if err != nil {
+			return "", err
+		}
+		s, ok := v.(string)
+		if !ok {
+			return "", fmt.Errorf("expected a string on the stack, got %v", v)
+		}
+		return s, nil
+	}
+	push := func(v interface{}) { stack = append(stack, v) }
+
+	var cursor int64
+	pc := 0
+	for pc < len(prog.Instructions) {
+		inst := prog.Instructions[pc]
+		switch inst.Op {
+		case OpPush:
+			if inst.IsStr {
+				push(inst.StrArg)
+			} else {
+				push(inst.IntArg)
+			}
+		case OpSeek:
+			n, err := popInt()
+			if err != nil {
+				return err
+			}
+			cursor = n
+			d.Seek(cursor)
+		case OpFind:
+			pattern, err := popStr()
+			if err != nil {
+				return err
+			}
+			offset, found := d.Find(pattern)
+			if found {
+				cursor = offset
+				push(int64(1))
+			} else {
+				push(int64(0))
+			}
+		case OpFindNext:
+			offset, found := d.FindNext()
+			if found {
+				cursor = offset
+				push(int64(1))
+			} else {
+				push(int64(0))
+			}
+		case OpMark:
+			d.AddHighlight(inst.IntArg)
+		case OpLabel:
+			vm.Symbols[inst.StrArg] = cursor
+		case OpJmp:
+			target, ok := prog.Labels[inst.StrArg]
+			if !ok {
+				return fmt.Errorf("undefined label %q", inst.StrArg)
+			}
+			pc = target
+			continue
+		case OpJz:
+			n, err := popInt()
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				target, ok := prog.Labels[inst.StrArg]
+				if !ok {
+					return fmt.Errorf("undefined label %q", inst.StrArg)
+				}
+				pc = target
+				continue
+			}
+		case OpHalt:
+			return nil
+		}
+		pc++
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package script
+
+import "testing"
+
+// fakeDispatcher records what a Program did for assertions.
+type fakeDispatcher struct {
+	seeks      []int64
+	highlights []int64
+	findHits   map[string]int64
+}
+
+func (f *fakeDispatcher) Seek(offset int64) {
+	f.seeks = append(f.seeks, offset)
+}
+
+func (f *fakeDispatcher) Find(pattern string) (int64, bool) {
+	offset, ok := f.findHits[pattern]
+	return offset, ok
+}
+
+func (f *fakeDispatcher) FindNext() (int64, bool) {
+	return 0, false
+}
+
+func (f *fakeDispatcher) AddHighlight(length int64) {
+	f.highlights = append(f.highlights, length)
+}
+
+func Test_VM_seekFindMark(t *testing.T) {
+	prog, err := Assemble(`
+		PUSH "4d 5a"
+		FIND
+		JZ notfound
+		MARK 64
+		LABEL header_end
+		JMP done
+		LABEL notfound
+		PUSH 0
+		SEEK
+		LABEL done
+		HALT
+	`)
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err.Error())
+	}
+
+	d := &fakeDispatcher{findHits: map[string]int64{"4d 5a": 0}}
+	vm := NewVM()
+	if err := vm.Run(prog, d); err != nil {
+		t.Fatalf("Run failed: %s", err.Error())
+	}
+
+	if len(d.highlights) != 1 || d.highlights[0] != 64 {
+		t.Errorf("expected a single 64-byte mark, got %v", d.highlights)
+	}
+	if got := vm.Symbols["header_end"]; got != 0 {
+		t.Errorf("expected header_end == 0, got %d", got)
+	}
+}
+
+func Test_VM_jzTakesBranchOnMiss(t *testing.T) {
+	prog, err := Assemble(`
+		PUSH "ff ff"
+		FIND
+		JZ notfound
+		MARK 1
+		JMP done
+		LABEL notfound
+		PUSH 42
+		SEEK
+		LABEL done
+		HALT
+	`)
+	if err != nil {
+		t.Fatalf("Assemble failed: %s", err.Error())
+	}
+
+	d := &fakeDispatcher{findHits: map[string]int64{}}
+	vm := NewVM()
+	if err := vm.Run(prog, d); err != nil {
+		t.Fatalf("Run failed: %s", err.Error())
+	}
+
+	if len(d.highlights) != 0 {
+		t.Errorf("expected no marks on a miss, got %v", d.highlights)
+	}
+	if len(d.seeks) != 1 || d.seeks[0] != 42 {
+		t.Errorf("expected a single seek to 42, got %v", d.seeks)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io"
+
+	"github.com/gdamore/tcell"
+)
+
+// scriptMarkColor is the highlight color given to annotations created
+// by the script VM's MARK instruction, since a script has no way to
+// name one of its own.
+const scriptMarkColor = tcell.ColorYellow
+
+// scriptDispatcher adapts an *AppState to script.Dispatcher so a
+// running script mutates binx's state the same way the UI does,
+// instead of needing its own copy of search/seek logic.
+type scriptDispatcher struct {
+	state *AppState
+
+	lastQuery Query
+	hasQuery  bool
+}
+
+// offsetReaderAt adapts r so a read at logical offset 0 starts at
+// base, letting FindAllInSource scan from an arbitrary start point
+// without first slicing everything before it into memory.
+type offsetReaderAt struct {
+	r    io.ReaderAt
+	base int64
+}
+
+func (o *offsetReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return o.r.ReadAt(p, o.base+off)
+}
+
+func (d *scriptDispatcher) Seek(offset int64) {
+	d.state.mutex.Lock()
+	defer d.state.mutex.Unlock()
+	d.state.startByte = clampStartByte(offset, d.state.fileSize)
+}
+
+func (d *scriptDispatcher) Find(pattern string) (int64, bool) {
+	q, err := ParseQuery(pattern)
+	if err != nil {
+		d.state.mutex.Lock()
+		d.state.status = err.Error()
+		d.state.mutex.Unlock()
+		return 0, false
+	}
+	d.lastQuery = q
+	d.hasQuery = true
+	return d.findFrom(0)
+}
+
+func (d *scriptDispatcher) FindNext() (int64, bool) {
+	if !d.hasQuery {
+		return 0, false
+	}
+	d.state.mutex.Lock()
+	from := d.state.startByte + 1
+	d.state.mutex.Unlock()
+	return d.findFrom(from)
+}
+
+func (d *scriptDispatcher) findFrom(from int64) (int64, bool) {
+	if from < 0 {
+		from = 0
+	}
+	if from >= d.state.fileSize {
+		return 0, false
+	}
+	hits := d.lastQuery.FindAllInSource(&offsetReaderAt{r: d.state.source, base: from}, d.state.fileSize-from)
+	if len(hits) == 0 {
+		return 0, false
+	}
+	offset := from + hits[0]
+
+	d.state.mutex.Lock()
+	d.state.highlights = append(d.state.highlights, offset)
+	d.state.startByte = alignToRow(offset, int64(d.state.byteVisWidth))
+	d.state.mutex.Unlock()
+	return offset, true
+}
+
+func (d *scriptDispatcher) AddHighlight(length int64) {
+	d.state.mutex.Lock()
+	defer d.state.mutex.Unlock()
+	d.state.sess.Annotate(d.state.startByte, length, int32(scriptMarkColor), "")
+}
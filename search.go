@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Query is a parsed search pattern ready for matching: a literal byte
+// sequence plus, for hex patterns, a per-byte mask of which bits must
+// match. A mask byte of 0xFF means the byte is fully fixed, 0x00 means
+// it's a "??" wildcard, and 0xF0/0x0F mean only one nibble is fixed.
+// mask is nil when every byte in pattern is fully fixed.
+type Query struct {
+	pattern []byte
+	mask    []byte
+}
+
+func (q Query) maskAt(i int) byte {
+	if q.mask == nil {
+		return 0xFF
+	}
+	return q.mask[i]
+}
+
+// matchAt reports whether q matches buf starting at pos. The caller is
+// responsible for ensuring pos+len(q.pattern) <= len(buf).
+func (q Query) matchAt(buf []byte, pos int) bool {
+	for i, pb := range q.pattern {
+		m := q.maskAt(i)
+		if buf[pos+i]&m != pb&m {
+			return false
+		}
+	}
+	return true
+}
+
+// numeralSpec describes a typed numeric literal prefix such as
+// "u32le:" or "f64be:".
+type numeralSpec struct {
+	size  int
+	order binary.ByteOrder
+	float bool
+}
+
+var numeralPrefixes = map[string]numeralSpec{
+	"u16le": {2, binary.LittleEndian, false},
+	"u16be": {2, binary.BigEndian, false},
+	"u32le": {4, binary.LittleEndian, false},
+	"u32be": {4, binary.BigEndian, false},
+	"u64le": {8, binary.LittleEndian, false},
+	"u64be": {8, binary.BigEndian, false},
+	"f32le": {4, binary.LittleEndian, true},
+	"f32be": {4, binary.BigEndian, true},
+	"f64le": {8, binary.LittleEndian, true},
+	"f64be": {8, binary.BigEndian, true},
+}
+
+// ParseQuery parses a search query typed into PatternInputMode. It
+// accepts, in order of precedence: a typed numeric literal
+// ("u32le:0xdeadbeef", "f64be:3.14"), a UTF-16LE/BE string literal
+// ("u16le:\"hi\""), a plain ASCII/UTF-8 string literal ("\"hi\""), or a
+// hex byte pattern with "??" nibble wildcards ("48 8b ?? 24").
+func ParseQuery(s string) (Query, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Query{}, fmt.Errorf("empty query")
+	}
+	if idx := strings.Index(s, ":"); idx > 0 {
+		if spec, ok := numeralPrefixes[s[:idx]]; ok {
+			rest := s[idx+1:]
+			if strings.HasPrefix(rest, "\"") {
+				return parseUTF16Literal(rest, spec.order)
+			}
+			return parseNumericLiteral(rest, spec)
+		}
+	}
+	if strings.HasPrefix(s, "\"") {
+		return parseStringLiteral(s)
+	}
+	return parseHexQuery(s)
+}
+
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("string literal must be wrapped in double quotes")
+	}
+	return s[1 : len(s)-1], nil
+}
+
+func parseStringLiteral(s string) (Query, error) {
+	unquoted, err := unquote(s)
+	if err != nil {
+		return Query{}, err
+	}
+	return Query{pattern: []byte(unquoted)}, nil
+}
+
+func parseUTF16Literal(s string, order binary.ByteOrder) (Query, error) {
+	unquoted, err := unquote(s)
+	if err != nil {
+		return Query{}, err
+	}
+	units := utf16.Encode([]rune(unquoted))
+	pattern := make([]byte, len(units)*2)
+	for i, u := range units {
+		order.PutUint16(pattern[i*2:], u)
+	}
+	return Query{pattern: pattern}, nil
+}
+
+func parseNumericLiteral(s string, spec numeralSpec) (Query, error) {
+	pattern := make([]byte, spec.size)
+	if spec.float {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return Query{}, err
+		}
+		if spec.size == 4 {
+			spec.order.PutUint32(pattern, math.Float32bits(float32(f)))
+		} else {
+			spec.order.PutUint64(pattern, math.Float64bits(f))
+		}
+		return Query{pattern: pattern}, nil
+	}
+	u, err := strconv.ParseUint(s, 0, spec.size*8)
+	if err != nil {
+		return Query{}, err
+	}
+	switch spec.size {
+	case 2:
+		spec.order.PutUint16(pattern, uint16(u))
+	case 4:
+		spec.order.PutUint32(pattern, uint32(u))
+	case 8:
+		spec.order.PutUint64(pattern, u)
+	}
+	return Query{pattern: pattern}, nil
+}
+
+// parseHexQuery parses a hex byte pattern such as "48 8b ?? 24", where
+// "?" stands for a wildcard nibble.
+func parseHexQuery(s string) (Query, error) {
+	s = strings.ReplaceAll(s, " ", "")
+	if len(s)%2 != 0 {
+		return Query{}, fmt.Errorf("hex pattern must have an even number of nibbles")
+	}
+	n := len(s) / 2
+	pattern := make([]byte, n)
+	mask := make([]byte, n)
+	wildcardSeen := false
+	for i := 0; i < n; i++ {
+		hi, lo := s[i*2], s[i*2+1]
+		var pb, mb byte
+		if hi == '?' {
+			wildcardSeen = true
+		} else {
+			v, err := hexNibble(hi)
+			if err != nil {
+				return Query{}, err
+			}
+			pb |= v << 4
+			mb |= 0xF0
+		}
+		if lo == '?' {
+			wildcardSeen = true
+		} else {
+			v, err := hexNibble(lo)
+			if err != nil {
+				return Query{}, err
+			}
+			pb |= v
+			mb |= 0x0F
+		}
+		pattern[i] = pb
+		mask[i] = mb
+	}
+	if !wildcardSeen {
+		mask = nil
+	}
+	return Query{pattern: pattern, mask: mask}, nil
+}
+
+func hexNibble(c byte) (byte, error) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', nil
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, nil
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, nil
+	}
+	return 0, fmt.Errorf("invalid hex digit %q", c)
+}
+
+// FindAll returns every offset in buf where q matches, in ascending
+// order, using a Boyer-Moore-Horspool scan when the pattern has enough
+// fixed bytes to build a useful skip table, falling back to a naive
+// scan otherwise (short or heavily-wildcarded patterns).
+func (q Query) FindAll(buf []byte) []int64 {
+	var hits []int64
+	m := len(q.pattern)
+	if m == 0 || m > len(buf) {
+		return hits
+	}
+
+	fixed := 0
+	for i := 0; i < m; i++ {
+		if q.maskAt(i) == 0xFF {
+			fixed++
+		}
+	}
+
+	var skip [256]int
+	useBMH := fixed >= 2
+	if useBMH {
+		// A wildcard position matches any byte, so no shift may carry the
+		// window past the last wildcard before the final position.
+		maxShift := m
+		for i := 0; i < m-1; i++ {
+			if q.maskAt(i) != 0xFF {
+				maxShift = m - 1 - i
+			}
+		}
+		for i := range skip {
+			skip[i] = maxShift
+		}
+		for i := 0; i < m-1; i++ {
+			if q.maskAt(i) == 0xFF && m-1-i < maxShift {
+				skip[q.pattern[i]] = m - 1 - i
+			}
+		}
+	}
+
+	for i := 0; i <= len(buf)-m; {
+		if q.matchAt(buf, i) {
+			hits = append(hits, int64(i))
+			i++
+			continue
+		}
+		if useBMH {
+			s := skip[buf[i+m-1]]
+			if s == 0 {
+				s = 1
+			}
+			i += s
+		} else {
+			i++
+		}
+	}
+	return hits
+}
+
+// searchWindowSize is the chunk size FindAllInSource streams from a
+// ByteSource at a time, so searching a file never requires holding
+// more than a window of it in memory, the same way pagedSource serves
+// reads out of fixed-size pages instead of the whole stream.
+const searchWindowSize = 1 << 20
+
+// FindAllInSource scans size bytes of r for q's pattern a window at a
+// time instead of requiring the caller to slice the whole file into
+// memory first. Consecutive windows overlap by len(pattern)-1 bytes so
+// a match straddling a window boundary isn't missed.
+func (q Query) FindAllInSource(r io.ReaderAt, size int64) []int64 {
+	var hits []int64
+	m := len(q.pattern)
+	if m == 0 || size < int64(m) {
+		return hits
+	}
+
+	windowSize := searchWindowSize
+	overlap := int64(m - 1)
+	if int64(windowSize) <= overlap {
+		windowSize = m * 2
+	}
+	step := int64(windowSize) - overlap
+
+	buf := make([]byte, windowSize)
+	for base := int64(0); base < size; base += step {
+		want := int64(windowSize)
+		if remaining := size - base; want > remaining {
+			want = remaining
+		}
+		n, err := r.ReadAt(buf[:want], base)
+		if err != nil && err != io.EOF {
+			break
+		}
+		for _, h := range q.FindAll(buf[:n]) {
+			hits = append(hits, base+h)
+		}
+		if int64(n) < want {
+			break
+		}
+	}
+	return hits
+}
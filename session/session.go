@@ -0,0 +1,203 @@
+// Package session persists binx's per-file view across runs: the last
+// scroll position, named bookmarks, user annotations on byte ranges,
+// and search history. Sessions are keyed by a fingerprint of the
+// file's first 64 KiB plus its size, so the right session is found
+// again even if the file has been renamed or moved.
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// fingerprintSize is how much of a file's head is hashed to build its
+// session key.
+const fingerprintSize = 64 * 1024
+
+// Annotation is a user-supplied note attached to a byte range.
+type Annotation struct {
+	Offset  int64  `json:"offset"`
+	Length  int64  `json:"length"`
+	Color   int32  `json:"color"`
+	Comment string `json:"comment"`
+}
+
+// Session is everything binx remembers about a single file between
+// runs.
+type Session struct {
+	StartByte   int64            `json:"startByte"`
+	Marks       map[string]int64 `json:"marks"`
+	Annotations []Annotation     `json:"annotations"`
+	History     []string         `json:"history"`
+}
+
+// New returns an empty Session ready to use.
+func New() *Session {
+	return &Session{Marks: map[string]int64{}}
+}
+
+// SetMark records name as a bookmark at offset.
+func (s *Session) SetMark(name string, offset int64) {
+	if s.Marks == nil {
+		s.Marks = map[string]int64{}
+	}
+	s.Marks[name] = offset
+}
+
+// Mark returns the offset bookmarked as name, if any.
+func (s *Session) Mark(name string) (int64, bool) {
+	offset, ok := s.Marks[name]
+	return offset, ok
+}
+
+// Annotate records a comment on the byte range [offset, offset+length).
+func (s *Session) Annotate(offset, length int64, color int32, comment string) {
+	s.Annotations = append(s.Annotations, Annotation{
+		Offset:  offset,
+		Length:  length,
+		Color:   color,
+		Comment: comment,
+	})
+}
+
+// AnnotationAt returns the first annotation covering offset, or nil if
+// none does.
+func (s *Session) AnnotationAt(offset int64) *Annotation {
+	for i := range s.Annotations {
+		a := &s.Annotations[i]
+		if offset >= a.Offset && offset < a.Offset+a.Length {
+			return a
+		}
+	}
+	return nil
+}
+
+// AddHistory appends query to the search history, skipping immediate
+// repeats of the last entry.
+func (s *Session) AddHistory(query string) {
+	if query == "" {
+		return
+	}
+	if n := len(s.History); n > 0 && s.History[n-1] == query {
+		return
+	}
+	s.History = append(s.History, query)
+}
+
+// Fingerprint derives the key a file's session is stored under from
+// the first fingerprintSize bytes plus its total size, the same way
+// decoders sniff a format from a leading slice of the file rather than
+// needing to read it all.
+func Fingerprint(r io.ReaderAt, size int64) (string, error) {
+	buf := make([]byte, fingerprintSize)
+	n, err := r.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(buf[:n])
+	binary.Write(h, binary.LittleEndian, size)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Store locates and persists Sessions on disk, one JSON file per
+// fingerprint, under dir.
+type Store struct {
+	dir string
+}
+
+// DefaultDir returns the directory binx stores sessions in under the
+// user's home directory.
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, ".binx", "sessions")
+}
+
+// NewStore returns a Store rooted at dir, creating it if it doesn't
+// exist yet.
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (st *Store) path(key string) string {
+	return filepath.Join(st.dir, key+".json")
+}
+
+// Load reads the session stored under key, returning a fresh empty
+// Session if none has been saved yet.
+func (st *Store) Load(key string) (*Session, error) {
+	data, err := os.ReadFile(st.path(key))
+	if os.IsNotExist(err) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sess := New()
+	if err := json.Unmarshal(data, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// Save writes sess under key, replacing whatever was stored before.
+func (st *Store) Save(key string, sess *Session) error {
+	return writeJSON(st.path(key), sess)
+}
+
+// Export writes sess as standalone, portable JSON to path so it can be
+// shared alongside a sample file.
+func Export(path string, sess *Session) error {
+	return writeJSON(path, sess)
+}
+
+// Import reads a Session previously written by Export.
+func Import(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sess := New()
+	if err := json.Unmarshal(data, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// writeJSON writes sess to path via a temp file plus rename, so a crash
+// or power loss mid-write can't leave a truncated file behind for the
+// next Load to choke on.
+func writeJSON(path string, sess *Session) error {
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
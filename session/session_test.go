@@ -0,0 +1,133 @@
+package session
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Session_marksAndAnnotations(t *testing.T) {
+	s := New()
+	s.SetMark("a", 128)
+
+	if offset, ok := s.Mark("a"); !ok || offset != 128 {
+		t.Fatalf("expected mark a at 128, got %d, %v", offset, ok)
+	}
+	if _, ok := s.Mark("z"); ok {
+		t.Fatalf("expected no mark z")
+	}
+
+	s.Annotate(100, 16, 0xff0000, "header")
+	if a := s.AnnotationAt(104); a == nil || a.Comment != "header" {
+		t.Fatalf("expected annotation covering 104, got %v", a)
+	}
+	if a := s.AnnotationAt(200); a != nil {
+		t.Fatalf("expected no annotation at 200, got %v", a)
+	}
+}
+
+func Test_Session_addHistorySkipsRepeats(t *testing.T) {
+	s := New()
+	s.AddHistory("4d 5a")
+	s.AddHistory("4d 5a")
+	s.AddHistory(`"PK"`)
+
+	want := []string{"4d 5a", `"PK"`}
+	if len(s.History) != len(want) {
+		t.Fatalf("expected history %v, got %v", want, s.History)
+	}
+	for i, q := range want {
+		if s.History[i] != q {
+			t.Errorf("history[%d] = %q, want %q", i, s.History[i], q)
+		}
+	}
+}
+
+func Test_Fingerprint_stableForSameContent(t *testing.T) {
+	data := bytes.Repeat([]byte{0x42}, 128)
+	r := bytes.NewReader(data)
+
+	a, err := Fingerprint(r, int64(len(data)))
+	if err != nil {
+		t.Fatalf("Fingerprint failed: %s", err.Error())
+	}
+	b, err := Fingerprint(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Fingerprint failed: %s", err.Error())
+	}
+	if a != b {
+		t.Errorf("expected stable fingerprint, got %q and %q", a, b)
+	}
+
+	c, err := Fingerprint(bytes.NewReader(data), int64(len(data))+1)
+	if err != nil {
+		t.Fatalf("Fingerprint failed: %s", err.Error())
+	}
+	if a == c {
+		t.Errorf("expected fingerprint to change with size")
+	}
+}
+
+func Test_Store_saveLoadRoundTrip(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore failed: %s", err.Error())
+	}
+
+	sess := New()
+	sess.StartByte = 4096
+	sess.SetMark("a", 64)
+	sess.Annotate(0, 4, 0x00ff00, "magic")
+
+	if err := store.Save("deadbeef", sess); err != nil {
+		t.Fatalf("Save failed: %s", err.Error())
+	}
+	got, err := store.Load("deadbeef")
+	if err != nil {
+		t.Fatalf("Load failed: %s", err.Error())
+	}
+	if got.StartByte != sess.StartByte {
+		t.Errorf("StartByte = %d, want %d", got.StartByte, sess.StartByte)
+	}
+	if offset, ok := got.Mark("a"); !ok || offset != 64 {
+		t.Errorf("Mark a = %d, %v, want 64, true", offset, ok)
+	}
+	if len(got.Annotations) != 1 || got.Annotations[0].Comment != "magic" {
+		t.Errorf("unexpected annotations: %v", got.Annotations)
+	}
+}
+
+func Test_Store_loadMissingReturnsEmpty(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore failed: %s", err.Error())
+	}
+	sess, err := store.Load("nope")
+	if err != nil {
+		t.Fatalf("Load failed: %s", err.Error())
+	}
+	if sess.StartByte != 0 || len(sess.Marks) != 0 {
+		t.Errorf("expected empty session, got %+v", sess)
+	}
+}
+
+func Test_ExportImport_roundTrip(t *testing.T) {
+	sess := New()
+	sess.SetMark("q", 512)
+	sess.Annotate(8, 2, 0, "flag")
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := Export(path, sess); err != nil {
+		t.Fatalf("Export failed: %s", err.Error())
+	}
+	got, err := Import(path)
+	if err != nil {
+		t.Fatalf("Import failed: %s", err.Error())
+	}
+	if offset, ok := got.Mark("q"); !ok || offset != 512 {
+		t.Errorf("Mark q = %d, %v, want 512, true", offset, ok)
+	}
+	if len(got.Annotations) != 1 || got.Annotations[0].Comment != "flag" {
+		t.Errorf("unexpected annotations: %v", got.Annotations)
+	}
+}